@@ -0,0 +1,219 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// servicePrincipal is the JSON shape expected in the file named by
+// AZURE_SERVICE_PRINCIPAL_FILE: an AAD app registration used to fetch OAuth
+// tokens via the client-credentials flow.
+type servicePrincipal struct {
+	TenantID     string `json:"tenantId"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+}
+
+// azureBackend talks to Azure Blob Storage's REST API, authenticating with
+// either a SAS token or an AAD service principal.
+type azureBackend struct {
+	account   string
+	container string
+	layout    KeyLayout
+	client    *http.Client
+
+	sasToken  string
+	principal *servicePrincipal
+
+	tokenMutex  sync.Mutex
+	cachedToken string
+	tokenExpiry time.Time
+}
+
+// NewAzureBackend builds a Backend for an Azure Blob container addressed as
+// azblob://account/container. It authenticates with a SAS token from
+// AZURE_STORAGE_SAS_TOKEN when set, or else an AAD service principal loaded
+// from the file named by AZURE_SERVICE_PRINCIPAL_FILE.
+func NewAzureBackend(u *url.URL, layout KeyLayout) (*azureBackend, error) {
+	b := &azureBackend{
+		account:   u.Host,
+		container: strings.Trim(u.Path, "/"),
+		layout:    defaultLayout(layout),
+		client:    &http.Client{},
+		sasToken:  os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+	}
+
+	if b.sasToken == "" {
+		if path := os.Getenv("AZURE_SERVICE_PRINCIPAL_FILE"); path != "" {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			var sp servicePrincipal
+			if err := json.Unmarshal(data, &sp); err != nil {
+				return nil, err
+			}
+			b.principal = &sp
+		}
+	}
+
+	return b, nil
+}
+
+func (b *azureBackend) blobURL(key string) string {
+	u := fmt.Sprintf("https://%s.blob.core.windows.net/%s/%s", b.account, b.container, key)
+	if b.sasToken != "" {
+		sep := "?"
+		if strings.Contains(u, "?") {
+			sep = "&"
+		}
+		u += sep + strings.TrimPrefix(b.sasToken, "?")
+	}
+	return u
+}
+
+// accessToken returns the current AAD bearer token, refreshing it via the
+// client-credentials flow once it's within a minute of expiring. It returns
+// "" when authenticating via a SAS token instead (or not at all).
+func (b *azureBackend) accessToken(ctx context.Context) (string, error) {
+	if b.principal == nil {
+		return "", nil
+	}
+
+	b.tokenMutex.Lock()
+	defer b.tokenMutex.Unlock()
+
+	if b.cachedToken != "" && time.Now().Add(time.Minute).Before(b.tokenExpiry) {
+		return b.cachedToken, nil
+	}
+
+	tokenURL := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", b.principal.TenantID)
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {b.principal.ClientID},
+		"client_secret": {b.principal.ClientSecret},
+		"scope":         {"https://storage.azure.com/.default"},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return "", fmt.Errorf("azblob: token request failed with status %d", res.StatusCode)
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&tokenResponse); err != nil {
+		return "", err
+	}
+
+	b.cachedToken = tokenResponse.AccessToken
+	b.tokenExpiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn) * time.Second)
+
+	return b.cachedToken, nil
+}
+
+func (b *azureBackend) do(ctx context.Context, method, key, rangeHeader string) (*http.Response, error) {
+	token, err := b.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, b.blobURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", "2021-08-06")
+	if rangeHeader != "" {
+		req.Header.Set("x-ms-range", rangeHeader)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return b.client.Do(req)
+}
+
+// doRetried is do wrapped in a retry/backoff policy, for request paths the
+// downloader doesn't already retry itself (Head, GetTag).
+func (b *azureBackend) doRetried(ctx context.Context, method, key, rangeHeader string) (*http.Response, error) {
+	var res *http.Response
+	err := retryWithBackoff(defaultRetryLimit, func() error {
+		var err error
+		res, err = b.do(ctx, method, key, rangeHeader)
+		return err
+	})
+	return res, err
+}
+
+func (b *azureBackend) Get(ctx context.Context, hash string) (io.ReadCloser, int64, error) {
+	res, err := b.do(ctx, http.MethodGet, b.layout.Key(hash), "")
+	if err != nil {
+		return nil, 0, err
+	}
+	if res.StatusCode >= 400 {
+		res.Body.Close()
+		return nil, 0, fmt.Errorf("azblob: bad response status %d for %s", res.StatusCode, hash)
+	}
+	return res.Body, res.ContentLength, nil
+}
+
+func (b *azureBackend) GetRange(ctx context.Context, hash string, start, end int64) (io.ReadCloser, error) {
+	res, err := b.do(ctx, http.MethodGet, b.layout.Key(hash), fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusPartialContent && res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("azblob: bad response status %d for %s", res.StatusCode, hash)
+	}
+	return res.Body, nil
+}
+
+func (b *azureBackend) Head(ctx context.Context, hash string) (int64, bool, error) {
+	res, err := b.doRetried(ctx, http.MethodHead, b.layout.Key(hash), "")
+	if err != nil {
+		return 0, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if res.StatusCode >= 400 {
+		return 0, false, fmt.Errorf("azblob: bad response status %d for %s", res.StatusCode, hash)
+	}
+	return res.ContentLength, true, nil
+}
+
+func (b *azureBackend) GetTag(ctx context.Context, name string) ([]byte, error) {
+	res, err := b.doRetried(ctx, http.MethodGet, "tag/"+name, "")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("azblob: bad response status %d for tag %s", res.StatusCode, name)
+	}
+	return ioutil.ReadAll(res.Body)
+}
@@ -0,0 +1,71 @@
+// Package backend abstracts where cfs content and tags actually live, so a
+// Downloader can be pointed at plain HTTP, a local mirror, or an object
+// store without any caller-visible change.
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// Backend is the minimal set of operations a Downloader needs from a
+// content store: fetch a hash's bytes, check whether it exists, and fetch a
+// tag by name.
+type Backend interface {
+	Get(ctx context.Context, hash string) (io.ReadCloser, int64, error)
+	Head(ctx context.Context, hash string) (int64, bool, error)
+	GetTag(ctx context.Context, name string) ([]byte, error)
+}
+
+// RangeGetter is implemented by backends that can serve a byte range
+// without downloading the whole object. Downloader's chunked resumable
+// fetch path uses it when available and falls back to Get otherwise.
+type RangeGetter interface {
+	GetRange(ctx context.Context, hash string, start, end int64) (io.ReadCloser, error)
+}
+
+// RangeProber is implemented by backends whose range support depends on the
+// specific remote server rather than being guaranteed by the backend's own
+// protocol — currently just plain HTTP, where an origin may silently ignore
+// a Range header and return the whole body instead of a 206. Downloader
+// checks it before attempting the chunked path, skipping straight to a
+// single-shot Get when it reports false instead of discovering the gap via
+// a failed checksum.
+type RangeProber interface {
+	SupportsRange(ctx context.Context, hash string) (bool, error)
+}
+
+// KeyLayout turns a content hash into the key a Backend looks it up by, so
+// operators can move between cfs's traditional two-character sharding and a
+// flat namespace without Downloader or its callers changing.
+type KeyLayout interface {
+	Key(hash string) string
+}
+
+// New builds the Backend addressed by rawurl's scheme: "http"/"https" (or no
+// scheme) for a plain HTTP server, "file" for a local mirror, "s3" for S3,
+// "gs" for GCS, and "azblob" for Azure Blob Storage. layout may be nil, in
+// which case each backend falls back to ShardedKeyLayout.
+func New(rawurl string, layout KeyLayout) (Backend, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "", "http", "https":
+		return NewHTTPBackend(u, layout), nil
+	case "file":
+		return NewFileBackend(u.Path, layout), nil
+	case "s3":
+		return NewS3Backend(u, layout)
+	case "gs":
+		return NewGCSBackend(u, layout)
+	case "azblob":
+		return NewAzureBackend(u, layout)
+	default:
+		return nil, fmt.Errorf("unsupported backend scheme %q", u.Scheme)
+	}
+}
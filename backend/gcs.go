@@ -0,0 +1,122 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// gcsBackend talks to GCS over its interoperable XML API.
+type gcsBackend struct {
+	bucket string
+	prefix string
+	layout KeyLayout
+	client *http.Client
+	token  string
+}
+
+// NewGCSBackend builds a Backend for a GCS bucket addressed as
+// gs://bucket/prefix. It authenticates with a bearer token from
+// GOOGLE_OAUTH_TOKEN when set (obtained out-of-band, e.g. via
+// `gcloud auth print-access-token`); a bucket with uniform public access
+// can be read without one.
+func NewGCSBackend(u *url.URL, layout KeyLayout) (*gcsBackend, error) {
+	return &gcsBackend{
+		bucket: u.Host,
+		prefix: strings.Trim(u.Path, "/"),
+		layout: defaultLayout(layout),
+		client: &http.Client{},
+		token:  os.Getenv("GOOGLE_OAUTH_TOKEN"),
+	}, nil
+}
+
+func (b *gcsBackend) objectURL(key string) string {
+	object := key
+	if b.prefix != "" {
+		object = b.prefix + "/" + key
+	}
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", b.bucket, object)
+}
+
+func (b *gcsBackend) do(ctx context.Context, method, key, rangeHeader string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.objectURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	if b.token != "" {
+		req.Header.Set("Authorization", "Bearer "+b.token)
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	return b.client.Do(req)
+}
+
+// doRetried is do wrapped in a retry/backoff policy, for request paths the
+// downloader doesn't already retry itself (Head, GetTag).
+func (b *gcsBackend) doRetried(ctx context.Context, method, key, rangeHeader string) (*http.Response, error) {
+	var res *http.Response
+	err := retryWithBackoff(defaultRetryLimit, func() error {
+		var err error
+		res, err = b.do(ctx, method, key, rangeHeader)
+		return err
+	})
+	return res, err
+}
+
+func (b *gcsBackend) Get(ctx context.Context, hash string) (io.ReadCloser, int64, error) {
+	res, err := b.do(ctx, http.MethodGet, b.layout.Key(hash), "")
+	if err != nil {
+		return nil, 0, err
+	}
+	if res.StatusCode >= 400 {
+		res.Body.Close()
+		return nil, 0, fmt.Errorf("gcs: bad response status %d for %s", res.StatusCode, hash)
+	}
+	return res.Body, res.ContentLength, nil
+}
+
+func (b *gcsBackend) GetRange(ctx context.Context, hash string, start, end int64) (io.ReadCloser, error) {
+	res, err := b.do(ctx, http.MethodGet, b.layout.Key(hash), fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusPartialContent && res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("gcs: bad response status %d for %s", res.StatusCode, hash)
+	}
+	return res.Body, nil
+}
+
+func (b *gcsBackend) Head(ctx context.Context, hash string) (int64, bool, error) {
+	res, err := b.doRetried(ctx, http.MethodHead, b.layout.Key(hash), "")
+	if err != nil {
+		return 0, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if res.StatusCode >= 400 {
+		return 0, false, fmt.Errorf("gcs: bad response status %d for %s", res.StatusCode, hash)
+	}
+	return res.ContentLength, true, nil
+}
+
+func (b *gcsBackend) GetTag(ctx context.Context, name string) ([]byte, error) {
+	res, err := b.doRetried(ctx, http.MethodGet, "tag/"+name, "")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("gcs: bad response status %d for tag %s", res.StatusCode, name)
+	}
+	return ioutil.ReadAll(res.Body)
+}
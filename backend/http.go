@@ -0,0 +1,162 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"runtime"
+)
+
+// httpBackend talks to a plain HTTP(S) server laid out the way cfs servers
+// always have: a tag/<name> endpoint and a data key per KeyLayout.
+type httpBackend struct {
+	baseURL *url.URL
+	layout  KeyLayout
+	client  *http.Client
+}
+
+func NewHTTPBackend(baseURL *url.URL, layout KeyLayout) *httpBackend {
+	return &httpBackend{
+		baseURL: baseURL,
+		layout:  defaultLayout(layout),
+		client:  &http.Client{Transport: newFileAwareTransport()},
+	}
+}
+
+func (b *httpBackend) dataURL(hash string) (*url.URL, error) {
+	return b.baseURL.Parse(b.layout.Key(hash))
+}
+
+func (b *httpBackend) Get(ctx context.Context, hash string) (io.ReadCloser, int64, error) {
+	u, err := b.dataURL(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	if res.StatusCode >= 400 {
+		res.Body.Close()
+		return nil, 0, fmt.Errorf("bad response status code %d from %v", res.StatusCode, u)
+	}
+
+	return res.Body, res.ContentLength, nil
+}
+
+func (b *httpBackend) GetRange(ctx context.Context, hash string, start, end int64) (io.ReadCloser, error) {
+	u, err := b.dataURL(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusPartialContent && res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("bad response status code %d from %v", res.StatusCode, u)
+	}
+
+	return res.Body, nil
+}
+
+// SupportsRange reports whether hash's server advertises "Accept-Ranges:
+// bytes", i.e. whether a GetRange against it can be trusted to actually
+// return a sub-range instead of silently falling back to the full body.
+func (b *httpBackend) SupportsRange(ctx context.Context, hash string) (bool, error) {
+	u, err := b.dataURL(hash)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+
+	return res.Header.Get("Accept-Ranges") == "bytes", nil
+}
+
+func (b *httpBackend) Head(ctx context.Context, hash string) (int64, bool, error) {
+	u, err := b.dataURL(hash)
+	if err != nil {
+		return 0, false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if res.StatusCode >= 400 {
+		return 0, false, fmt.Errorf("bad response status code %d from %v", res.StatusCode, u)
+	}
+
+	return res.ContentLength, true, nil
+}
+
+func (b *httpBackend) GetTag(ctx context.Context, name string) ([]byte, error) {
+	u, err := b.baseURL.Parse("tag/" + name)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("bad response status code %d from %v", res.StatusCode, u)
+	}
+
+	return ioutil.ReadAll(res.Body)
+}
+
+func newFileAwareTransport() *http.Transport {
+	t := &http.Transport{}
+	if runtime.GOOS == "windows" {
+		t.RegisterProtocol("file", http.NewFileTransport(http.Dir("")))
+	} else {
+		t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+	}
+	return t
+}
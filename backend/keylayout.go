@@ -0,0 +1,32 @@
+package backend
+
+import "fmt"
+
+// ShardedKeyLayout is the layout cfs has always used: a two-character
+// shard directory followed by the rest of the hash, e.g.
+// "data/ab/cdef0123...". It keeps any single directory from accumulating
+// too many entries on filesystems that care.
+type ShardedKeyLayout struct{}
+
+func (ShardedKeyLayout) Key(hash string) string {
+	if len(hash) < 2 {
+		return "data/" + hash
+	}
+	return fmt.Sprintf("data/%s/%s", hash[:2], hash[2:])
+}
+
+// FlatKeyLayout stores every hash directly under "data/". Object stores
+// don't pay the same per-directory cost a filesystem does, so operators
+// using one of those backends can opt into a simpler, flat namespace.
+type FlatKeyLayout struct{}
+
+func (FlatKeyLayout) Key(hash string) string {
+	return "data/" + hash
+}
+
+func defaultLayout(layout KeyLayout) KeyLayout {
+	if layout != nil {
+		return layout
+	}
+	return ShardedKeyLayout{}
+}
@@ -0,0 +1,19 @@
+package backend
+
+import "testing"
+
+func TestShardedKeyLayout(t *testing.T) {
+	got := ShardedKeyLayout{}.Key("0123456789abcdef0123456789abcdef")
+	want := "data/01/23456789abcdef0123456789abcdef"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFlatKeyLayout(t *testing.T) {
+	got := FlatKeyLayout{}.Key("0123456789abcdef0123456789abcdef")
+	want := "data/0123456789abcdef0123456789abcdef"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
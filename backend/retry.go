@@ -0,0 +1,30 @@
+package backend
+
+import "time"
+
+// defaultRetryLimit bounds the per-backend retry/backoff policy s3/gcs/azure
+// use for request paths the downloader doesn't already retry itself (Head,
+// GetTag). Get/GetRange are retried one layer up, by Downloader.fetch, so
+// they're deliberately left unwrapped here to avoid compounding retries.
+const defaultRetryLimit = 5
+
+func retryWithBackoff(limit int, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < limit; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoffDelay(attempt))
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func backoffDelay(attempt int) time.Duration {
+	delay := time.Duration(1<<uint(attempt)) * 200 * time.Millisecond
+	if delay > 10*time.Second {
+		return 10 * time.Second
+	}
+	return delay
+}
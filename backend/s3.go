@@ -0,0 +1,226 @@
+package backend
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// emptyPayloadHash is the SHA-256 hash of an empty body, used by every
+// request this backend sends since it never uploads data.
+var emptyPayloadHash = hashHex(nil)
+
+// s3Backend talks to S3 directly over its REST API, signing every request
+// with Signature Version 4 rather than pulling in the AWS SDK.
+type s3Backend struct {
+	bucket string
+	prefix string
+	region string
+	layout KeyLayout
+	client *http.Client
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// NewS3Backend builds a Backend for an S3 bucket addressed as
+// s3://bucket/prefix. Credentials come from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN (optional), and
+// AWS_REGION/AWS_DEFAULT_REGION, the same env vars the AWS CLI uses.
+func NewS3Backend(u *url.URL, layout KeyLayout) (*s3Backend, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	return &s3Backend{
+		bucket:          u.Host,
+		prefix:          strings.Trim(u.Path, "/"),
+		region:          region,
+		layout:          defaultLayout(layout),
+		client:          &http.Client{},
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}, nil
+}
+
+func (b *s3Backend) objectKey(key string) string {
+	if b.prefix == "" {
+		return key
+	}
+	return b.prefix + "/" + key
+}
+
+func (b *s3Backend) do(ctx context.Context, method, key, rangeHeader string) (*http.Response, error) {
+	objectKey := b.objectKey(key)
+	reqURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", b.bucket, b.region, objectKey)
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	b.sign(req, objectKey)
+
+	return b.client.Do(req)
+}
+
+// doRetried is do wrapped in a retry/backoff policy, for request paths the
+// downloader doesn't already retry itself (Head, GetTag).
+func (b *s3Backend) doRetried(ctx context.Context, method, key, rangeHeader string) (*http.Response, error) {
+	var res *http.Response
+	err := retryWithBackoff(defaultRetryLimit, func() error {
+		var err error
+		res, err = b.do(ctx, method, key, rangeHeader)
+		return err
+	})
+	return res, err
+}
+
+// sign adds AWS Signature Version 4 headers to req for objectKey.
+func (b *s3Backend) sign(req *http.Request, objectKey string) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", emptyPayloadHash)
+	if b.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", b.sessionToken)
+	}
+
+	signedHeaders, canonicalHeaders := canonicalizeHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/" + objectKey,
+		"",
+		canonicalHeaders,
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	credentialScope := fmt.Sprintf("%s/%s/s3/aws4_request", dateStamp, b.region)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		credentialScope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := deriveSigningKey(b.secretAccessKey, dateStamp, b.region, "s3")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		b.accessKeyID, credentialScope, signedHeaders, signature,
+	))
+}
+
+func canonicalizeHeaders(req *http.Request) (signedHeaders, canonical string) {
+	type header struct{ name, value string }
+	headers := []header{
+		{"host", req.Header.Get("Host")},
+		{"x-amz-content-sha256", req.Header.Get("x-amz-content-sha256")},
+		{"x-amz-date", req.Header.Get("x-amz-date")},
+	}
+	if t := req.Header.Get("x-amz-security-token"); t != "" {
+		headers = append(headers, header{"x-amz-security-token", t})
+	}
+	sort.Slice(headers, func(i, j int) bool { return headers[i].name < headers[j].name })
+
+	names := make([]string, len(headers))
+	var b strings.Builder
+	for i, h := range headers {
+		names[i] = h.name
+		fmt.Fprintf(&b, "%s:%s\n", h.name, h.value)
+	}
+
+	return strings.Join(names, ";"), b.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func deriveSigningKey(secret, dateStamp, region, service string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secret), dateStamp)
+	kRegion := hmacSHA256(kDate, region)
+	kService := hmacSHA256(kRegion, service)
+	return hmacSHA256(kService, "aws4_request")
+}
+
+func (b *s3Backend) Get(ctx context.Context, hash string) (io.ReadCloser, int64, error) {
+	res, err := b.do(ctx, http.MethodGet, b.layout.Key(hash), "")
+	if err != nil {
+		return nil, 0, err
+	}
+	if res.StatusCode >= 400 {
+		res.Body.Close()
+		return nil, 0, fmt.Errorf("s3: bad response status %d for %s", res.StatusCode, hash)
+	}
+	return res.Body, res.ContentLength, nil
+}
+
+func (b *s3Backend) GetRange(ctx context.Context, hash string, start, end int64) (io.ReadCloser, error) {
+	res, err := b.do(ctx, http.MethodGet, b.layout.Key(hash), fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusPartialContent && res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("s3: bad response status %d for %s", res.StatusCode, hash)
+	}
+	return res.Body, nil
+}
+
+func (b *s3Backend) Head(ctx context.Context, hash string) (int64, bool, error) {
+	res, err := b.doRetried(ctx, http.MethodHead, b.layout.Key(hash), "")
+	if err != nil {
+		return 0, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if res.StatusCode >= 400 {
+		return 0, false, fmt.Errorf("s3: bad response status %d for %s", res.StatusCode, hash)
+	}
+	return res.ContentLength, true, nil
+}
+
+func (b *s3Backend) GetTag(ctx context.Context, name string) ([]byte, error) {
+	res, err := b.doRetried(ctx, http.MethodGet, "tag/"+name, "")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return nil, fmt.Errorf("s3: bad response status %d for tag %s", res.StatusCode, name)
+	}
+	return ioutil.ReadAll(res.Body)
+}
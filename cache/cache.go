@@ -0,0 +1,219 @@
+// Package cache wraps a content-addressed directory with a bounded LRU, so
+// long-running fetch tools don't grow their local cache without limit.
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/natefinch/atomic"
+)
+
+// entry is one hash's bookkeeping record in the cache index.
+type entry struct {
+	Size       int64     `json:"size"`
+	LastAccess time.Time `json:"lastAccess"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Stats summarizes the current state of a Cache, as reported by Stat.
+type Stats struct {
+	EntryCount int
+	TotalBytes int64
+}
+
+// Cache wraps a directory of content-addressed files with a bounded LRU: an
+// index.json tracks size and access time per hash so Prune can evict the
+// least-recently-used entries once the cache exceeds Config.MaxBytes, and
+// drop any entry older than Config.MaxAge.
+type Cache struct {
+	cfg Config
+
+	mutex sync.Mutex
+	index map[string]entry
+}
+
+// New opens (or initializes) a Cache rooted at cfg.Dir.
+func New(cfg Config) (*Cache, error) {
+	if err := os.MkdirAll(cfg.Dir, 0777); err != nil {
+		return nil, err
+	}
+
+	c := &Cache{cfg: cfg}
+	if err := c.loadIndex(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Path returns the on-disk path hash would be stored at, for callers (like
+// Downloader) that need to write it directly rather than through Put.
+func (c *Cache) Path(hash string) string {
+	return c.dataPath(hash)
+}
+
+func (c *Cache) indexPath() string {
+	return filepath.Join(c.cfg.Dir, "index.json")
+}
+
+func (c *Cache) dataPath(hash string) string {
+	return filepath.Join(c.cfg.Dir, hash)
+}
+
+func (c *Cache) loadIndex() error {
+	data, err := ioutil.ReadFile(c.indexPath())
+	if os.IsNotExist(err) {
+		c.index = make(map[string]entry)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]entry)
+	if err := json.Unmarshal(data, &index); err != nil {
+		return err
+	}
+	c.index = index
+	return nil
+}
+
+func (c *Cache) saveIndex() error {
+	data, err := json.Marshal(c.index)
+	if err != nil {
+		return err
+	}
+	return atomic.WriteFile(c.indexPath(), bytes.NewBuffer(data))
+}
+
+// Get returns the cached reader for hash, touching its last-access time, or
+// (nil, false) if hash isn't cached.
+func (c *Cache) Get(hash string) (io.ReadCloser, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.index[hash]
+	if !ok {
+		return nil, false
+	}
+
+	f, err := os.Open(c.dataPath(hash))
+	if err != nil {
+		delete(c.index, hash)
+		c.saveIndex()
+		return nil, false
+	}
+
+	e.LastAccess = time.Now()
+	c.index[hash] = e
+	c.saveIndex()
+
+	return f, true
+}
+
+// Put writes r to the cache under hash and records it in the index, then
+// evicts entries until the cache fits Config.MaxBytes/MaxAge again.
+func (c *Cache) Put(hash string, r io.Reader) error {
+	if err := atomic.WriteFile(c.dataPath(hash), r); err != nil {
+		return err
+	}
+	return c.Register(hash)
+}
+
+// Register adds an already-written cache entry (one callers wrote directly
+// to Path(hash), e.g. via a resumable chunked download) to the index and
+// runs eviction, without touching the file itself.
+func (c *Cache) Register(hash string) error {
+	info, err := os.Stat(c.dataPath(hash))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	c.mutex.Lock()
+	c.index[hash] = entry{Size: info.Size(), LastAccess: now, CreatedAt: now}
+	err = c.saveIndex()
+	c.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return c.Prune(context.Background())
+}
+
+// Prune evicts least-recently-used entries until the cache's total size is
+// at or below Config.MaxBytes, and removes any entry older than
+// Config.MaxAge (if set). It runs automatically after every Put/Register,
+// but is exported so a CLI subcommand can enforce limits on demand.
+func (c *Cache) Prune(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	if c.cfg.MaxAge > 0 {
+		for hash, e := range c.index {
+			if now.Sub(e.CreatedAt) > c.cfg.MaxAge {
+				c.evictLocked(hash)
+			}
+		}
+	}
+
+	if c.cfg.MaxBytes > 0 {
+		type ordered struct {
+			hash string
+			e    entry
+		}
+		all := make([]ordered, 0, len(c.index))
+		var total int64
+		for hash, e := range c.index {
+			all = append(all, ordered{hash, e})
+			total += e.Size
+		}
+		sort.Slice(all, func(i, j int) bool {
+			return all[i].e.LastAccess.Before(all[j].e.LastAccess)
+		})
+
+		for _, o := range all {
+			if total <= c.cfg.MaxBytes {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+			c.evictLocked(o.hash)
+			total -= o.e.Size
+		}
+	}
+
+	return c.saveIndex()
+}
+
+func (c *Cache) evictLocked(hash string) {
+	os.Remove(c.dataPath(hash))
+	delete(c.index, hash)
+}
+
+// Stat reports the cache's current entry count and total size.
+func (c *Cache) Stat() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := Stats{EntryCount: len(c.index)}
+	for _, e := range c.index {
+		stats.TotalBytes += e.Size
+	}
+	return stats
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPutGet(t *testing.T) {
+	c, err := New(Config{Dir: t.TempDir()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := c.Put("hash1", strings.NewReader("hello")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	r, ok := c.Get("hash1")
+	if !ok {
+		t.Error("expected hash1 to be cached")
+		return
+	}
+	defer r.Close()
+
+	buf := make([]byte, 5)
+	if _, err := r.Read(buf); err != nil {
+		t.Error(err)
+		return
+	}
+	if string(buf) != "hello" {
+		t.Errorf("got %q, want %q", buf, "hello")
+	}
+
+	if _, ok := c.Get("missing"); ok {
+		t.Error("expected missing to not be cached")
+	}
+}
+
+func TestPruneEvictsLeastRecentlyUsed(t *testing.T) {
+	c, err := New(Config{Dir: t.TempDir(), MaxBytes: 10})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := c.Put("old", strings.NewReader("12345")); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := c.Put("new", strings.NewReader("12345")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Touch "old" so it becomes the most recently used entry.
+	if _, ok := c.Get("old"); !ok {
+		t.Error("expected old to still be cached before eviction")
+		return
+	}
+
+	if err := c.Put("newest", strings.NewReader("12345")); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, ok := c.Get("new"); ok {
+		t.Error("expected new to have been evicted as least-recently-used")
+	}
+	if _, ok := c.Get("old"); !ok {
+		t.Error("expected old to survive eviction after being touched")
+	}
+
+	stats := c.Stat()
+	if stats.TotalBytes > 10 {
+		t.Errorf("cache exceeds MaxBytes: %d", stats.TotalBytes)
+	}
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config controls how an on-disk Cache is bounded and where it lives.
+type Config struct {
+	// MaxBytes is the total on-disk size the cache is trimmed back to after
+	// every write. 0 disables size-based eviction.
+	MaxBytes int64
+
+	// MaxAge drops any entry older than this regardless of size pressure.
+	// -1 means entries never expire by age; 0 (the zero value) disables
+	// age-based eviction.
+	MaxAge time.Duration
+
+	// Dir is the cache's root directory.
+	Dir string
+}
+
+type rawConfig struct {
+	MaxBytes int64  `json:"maxBytes"`
+	MaxAge   string `json:"maxAge"`
+	Dir      string `json:"dir"`
+}
+
+// LoadConfig reads a cache Config from a cfs config file at path. Dir may
+// contain the ":cacheDir" and ":tempDir" placeholders, expanded against the
+// platform's user cache directory and temp directory, so CI can point the
+// cache at a provided path without a hardcoded config per environment.
+// MaxAge is parsed with time.ParseDuration, except for the literal "-1"
+// which means "never expire".
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw rawConfig
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	maxAge, err := parseMaxAge(raw.MaxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Config{
+		MaxBytes: raw.MaxBytes,
+		MaxAge:   maxAge,
+		Dir:      expandPlaceholders(raw.Dir),
+	}, nil
+}
+
+func parseMaxAge(s string) (time.Duration, error) {
+	switch s {
+	case "", "0":
+		return 0, nil
+	case "-1":
+		return -1, nil
+	default:
+		return time.ParseDuration(s)
+	}
+}
+
+func expandPlaceholders(dir string) string {
+	if strings.Contains(dir, ":cacheDir") {
+		if userCacheDir, err := os.UserCacheDir(); err == nil {
+			dir = strings.ReplaceAll(dir, ":cacheDir", userCacheDir)
+		}
+	}
+	if strings.Contains(dir, ":tempDir") {
+		dir = strings.ReplaceAll(dir, ":tempDir", os.TempDir())
+	}
+	return dir
+}
@@ -13,9 +13,13 @@ import (
 	"local.package/cfs/pack"
 )
 
-func filterBucket(cmd string, b *cfs.Bucket) (*cfs.Bucket, error) {
+// filterBucket narrows b to the entries cmd selects (or all of them, if cmd
+// is empty), then hands the result to autoPackBucket so any file small
+// enough to fall under packSizeThreshold ships bundled into a pack instead
+// of as its own object.
+func filterBucket(cmd string, b *cfs.Bucket, dataDir, packDir string) (*cfs.Bucket, error) {
 	if cmd == "" {
-		return b, nil
+		return autoPackBucket(b, dataDir, packDir)
 	}
 
 	entries := b.Contents
@@ -40,11 +44,13 @@ func filterBucket(cmd string, b *cfs.Bucket) (*cfs.Bucket, error) {
 	}
 	entries = newEntries
 
-	return &cfs.Bucket{
+	filtered := &cfs.Bucket{
 		HashType: "md5",
 		Contents: entries,
 		Tag:      b.Tag,
-	}, nil
+	}
+
+	return autoPackBucket(filtered, dataDir, packDir)
 }
 
 func filterPackFile(cmd string, pak *pack.PackFile) (*pack.PackFile, error) {
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+
+	"local.package/cfs"
+	"local.package/cfs/pack"
+)
+
+// packSizeThreshold is the size below which filterBucket groups a content
+// entry into a pack instead of leaving it to ship as its own HTTP object.
+const packSizeThreshold = 1 << 20 // 1 MiB
+
+// splitForPacking partitions a filtered bucket's contents into files large
+// enough to ship individually ("loose") and small files that should be
+// bundled into a pack to cut per-file HTTP overhead ("packable").
+func splitForPacking(b *cfs.Bucket, threshold int64) (loose, packable map[string]cfs.Content) {
+	loose = make(map[string]cfs.Content, len(b.Contents))
+	packable = make(map[string]cfs.Content, len(b.Contents))
+	for path, c := range b.Contents {
+		if c.Size > 0 && c.Size < threshold {
+			packable[path] = c
+		} else {
+			loose[path] = c
+		}
+	}
+	return loose, packable
+}
+
+// buildPack writes the blobs for contents (read from dataDir, named by
+// hash) into a single pack file at packPath, and returns the index entries
+// that let a Downloader later slice each one back out of it.
+func buildPack(contents map[string]cfs.Content, dataDir, packPath, packHash string) (map[string]pack.IndexEntry, error) {
+	entries := make([]pack.Entry, 0, len(contents))
+	for path, c := range contents {
+		entries = append(entries, pack.Entry{Path: path, Hash: c.Hash, Size: c.Size})
+	}
+
+	out, err := os.Create(packPath)
+	check(err)
+	defer out.Close()
+
+	built, err := pack.Build(out, entries, func(hash string) (io.Reader, error) {
+		return os.Open(filepath.Join(dataDir, hash))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	headerSize, err := pack.HeaderSize(built)
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]pack.IndexEntry, len(built.Entries))
+	for _, e := range built.Entries {
+		index[e.Hash] = pack.IndexEntry{
+			PackHash: packHash,
+			Offset:   headerSize + e.Pos,
+			Size:     e.Size,
+		}
+	}
+
+	return index, nil
+}
+
+// autoPackBucket groups b's small files (per splitForPacking/
+// packSizeThreshold) into a single pack file under packDir, with blobs read
+// from dataDir, and writes the resulting index alongside it as index.json.
+// It returns a Bucket containing only the loose entries, since packed ones
+// are now served by loading that index (pack.ParseIndex) into a
+// Downloader's PackIndex instead of being fetched individually. If nothing
+// in b is small enough to pack, b is returned unchanged.
+func autoPackBucket(b *cfs.Bucket, dataDir, packDir string) (*cfs.Bucket, error) {
+	loose, packable := splitForPacking(b, packSizeThreshold)
+	if len(packable) == 0 {
+		return b, nil
+	}
+
+	if err := os.MkdirAll(packDir, 0777); err != nil {
+		return nil, err
+	}
+
+	tmpPath := filepath.Join(packDir, ".pack.tmp")
+	index, err := buildPack(packable, dataDir, tmpPath, "")
+	if err != nil {
+		return nil, err
+	}
+
+	packHash, err := hashFile(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	for hash, e := range index {
+		e.PackHash = packHash
+		index[hash] = e
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(packDir, packHash)); err != nil {
+		return nil, err
+	}
+
+	idx := pack.NewPackIndex()
+	idx.Entries = index
+
+	idxFile, err := os.Create(filepath.Join(packDir, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+	defer idxFile.Close()
+	if err := pack.WriteIndex(idxFile, idx); err != nil {
+		return nil, err
+	}
+
+	return &cfs.Bucket{
+		HashType: b.HashType,
+		Contents: loose,
+		Tag:      b.Tag,
+	}, nil
+}
+
+// hashFile returns path's content hex-encoded MD5, the same way every other
+// piece of content in cfs is named.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sum := md5.New()
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
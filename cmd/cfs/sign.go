@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"local.package/cfs"
+)
+
+// signTag signs the TagFile at tagPath under keyID using the raw Ed25519
+// private key at keyPath, and overwrites tagPath with the signed result.
+func signTag(keyPath, keyID, tagPath string) error {
+	keyBytes, err := ioutil.ReadFile(keyPath)
+	check(err)
+
+	keyBytes = bytes.TrimSpace(keyBytes)
+	if len(keyBytes) != ed25519.PrivateKeySize {
+		return fmt.Errorf("%s: want a %d-byte Ed25519 private key, got %d bytes", keyPath, ed25519.PrivateKeySize, len(keyBytes))
+	}
+
+	tag, err := cfs.TagFileFromFile(tagPath)
+	check(err)
+
+	err = cfs.SignTagFile(ed25519.PrivateKey(keyBytes), keyID, tag)
+	check(err)
+
+	out, err := json.MarshalIndent(tag, "", "  ")
+	check(err)
+
+	return ioutil.WriteFile(tagPath, out, 0644)
+}
+
+// verifyTag registers the raw Ed25519 public key at pubKeyPath under the
+// tag's own PublicKeyID and reports whether the TagFile at tagPath verifies
+// against it.
+func verifyTag(pubKeyPath, tagPath string) error {
+	pubBytes, err := ioutil.ReadFile(pubKeyPath)
+	check(err)
+
+	pubBytes = bytes.TrimSpace(pubBytes)
+	if len(pubBytes) != ed25519.PublicKeySize {
+		return fmt.Errorf("%s: want a %d-byte Ed25519 public key, got %d bytes", pubKeyPath, ed25519.PublicKeySize, len(pubBytes))
+	}
+
+	tag, err := cfs.TagFileFromFile(tagPath)
+	check(err)
+
+	cfs.RegisterVerifier(tag.PublicKeyID, ed25519.PublicKey(pubBytes))
+
+	if err := tag.Verify(); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s: signature OK (key %s)\n", tagPath, tag.PublicKeyID)
+	return nil
+}
@@ -3,35 +3,109 @@ package cfs
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"net/http"
-	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/natefinch/atomic"
 	"golang.org/x/sync/errgroup"
+
+	"local.package/cfs/backend"
+	"local.package/cfs/cache"
+	"local.package/cfs/pack"
 )
 
+// DefaultChunkSize is used when Downloader.ChunkSize is left unset.
+const DefaultChunkSize int64 = 32 * 1024 * 1024
+
+// DefaultChunkConcurrency is the number of chunk fetches issued concurrently
+// for a single file when Downloader.ChunkConcurrency is left unset.
+const DefaultChunkConcurrency = 4
+
+const chunkRetryLimit = 5
+
 type Downloader struct {
-	BaseUrl *url.URL
+	// Backend is where content and tags actually live. NewDownloader builds
+	// one from the scheme of the URL it's given (http(s), file, s3, gs, or
+	// azblob); set it directly to use a backend NewDownloader can't infer.
+	Backend backend.Backend
+
+	// ChunkSize is the size in bytes of each ranged GET issued against a
+	// single file. Defaults to DefaultChunkSize.
+	ChunkSize int64
+
+	// ChunkConcurrency is the number of chunks fetched in parallel for a
+	// single file. Defaults to DefaultChunkConcurrency.
+	ChunkConcurrency int
+
+	// PackIndex maps content hashes packed via the pack subsystem to their
+	// location, letting Fetch serve them by slicing a cached pack instead of
+	// downloading each one individually. Set it with LoadPackIndex. Nil
+	// means no content in this Bucket is packed.
+	PackIndex *pack.PackIndex
+
+	// Cache backs Fetch's local storage with a size/age-bounded LRU instead
+	// of an unbounded directory. NewDownloader sets it to an unbounded cache
+	// rooted at GlobalDataCacheDir(); Fetch does not work with it left nil.
+	// Set it to a *cache.Cache built from cache.LoadConfig to enforce limits.
+	Cache *cache.Cache
+
+	// Reporter receives structured progress events from FetchAll and Sync.
+	// Defaults to a silent implementation; set it (or use WithReporter) to
+	// drive a progress bar or structured log instead of the Verbose prints.
+	Reporter ProgressReporter
 }
 
 func NewDownloader(baseRawurl string) (*Downloader, error) {
-	url, err := url.Parse(baseRawurl)
+	be, err := backend.New(baseRawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	dataCache, err := cache.New(cache.Config{Dir: GlobalDataCacheDir()})
 	if err != nil {
 		return nil, err
 	}
 
 	downloader := &Downloader{
-		BaseUrl: url,
+		Backend:          be,
+		ChunkSize:        DefaultChunkSize,
+		ChunkConcurrency: DefaultChunkConcurrency,
+		Cache:            dataCache,
 	}
 
 	return downloader, nil
 }
 
+func (d *Downloader) chunkSize() int64 {
+	if d.ChunkSize > 0 {
+		return d.ChunkSize
+	}
+	return DefaultChunkSize
+}
+
+func (d *Downloader) chunkConcurrency() int {
+	if d.ChunkConcurrency > 0 {
+		return d.ChunkConcurrency
+	}
+	return DefaultChunkConcurrency
+}
+
+func (d *Downloader) reporter() ProgressReporter {
+	if d.Reporter != nil {
+		return d.Reporter
+	}
+	return silentReporter{}
+}
+
 func (d *Downloader) LoadBucket(location string) (*Bucket, error) {
 
 	b := &Bucket{
@@ -45,7 +119,10 @@ func (d *Downloader) LoadBucket(location string) (*Bucket, error) {
 		if err != nil {
 			return nil, err
 		}
-		location = string(locationBytes)
+		location, err = resolveTagHash(locationBytes)
+		if err != nil {
+			return nil, err
+		}
 		if !isHash(location) {
 			return nil, fmt.Errorf("%s is not hash", location)
 		}
@@ -64,11 +141,37 @@ func (d *Downloader) LoadBucket(location string) (*Bucket, error) {
 	return b, nil
 }
 
+// LoadPackIndex fetches and parses the pack index at location (a hash or a
+// tag resolving to one), the same way LoadBucket resolves a Bucket. Assign
+// the result to Downloader.PackIndex before calling Fetch/FetchAll so packed
+// content is served from local packs instead of being downloaded whole.
+func (d *Downloader) LoadPackIndex(location string) (*pack.PackIndex, error) {
+	if !isHash(location) {
+		locationBytes, err := d.FetchTag(location)
+		if err != nil {
+			return nil, err
+		}
+		location, err = resolveTagHash(locationBytes)
+		if err != nil {
+			return nil, err
+		}
+		if !isHash(location) {
+			return nil, fmt.Errorf("%s is not hash", location)
+		}
+	}
+
+	body, err := d.Fetch(location, DefaultContentAttribute())
+	if err != nil {
+		return nil, err
+	}
+
+	return pack.ParseIndex(bytes.NewReader(body))
+}
+
 func (d *Downloader) ExistsAll(b *Bucket) (map[string]bool, error) {
 	result := map[string]bool{}
 	mutex := sync.Mutex{}
 
-	transport := &http.Transport{}
 	wg := sync.WaitGroup{}
 	ch := make(chan Content, 32)
 
@@ -80,19 +183,9 @@ func (d *Downloader) ExistsAll(b *Bucket) (map[string]bool, error) {
 				if Verbose {
 					fmt.Printf("verifying %s (%s)\n", c.Path, c.Hash)
 				}
-				url, err := d.dataUrl(c.Hash)
-				if err != nil {
-					panic(err)
-				}
-				res, err := headRequest(transport, url)
-				if err != nil {
-					mutex.Lock()
-					result[c.Path] = false
-					mutex.Unlock()
-					continue
-				}
+				_, exists, err := d.Backend.Head(context.Background(), c.Hash)
 				mutex.Lock()
-				result[c.Path] = (res.StatusCode == 200)
+				result[c.Path] = err == nil && exists
 				mutex.Unlock()
 			}
 		}()
@@ -108,28 +201,35 @@ func (d *Downloader) ExistsAll(b *Bucket) (map[string]bool, error) {
 	return result, nil
 }
 
-func (d *Downloader) Sync(b *Bucket, dir string) error {
+func (d *Downloader) Sync(b *Bucket, dir string) (err error) {
+	reporter := d.reporter()
+	reporter.OnStart(int64(len(b.Contents)), totalSize(b))
+	defer func() { reporter.OnDone(err) }()
+
 	for _, c := range b.Contents {
 		if Verbose {
 			fmt.Printf("downloading %s\n", c.Path)
 		}
+		reporter.OnFileStart(c.Path, c.Hash, c.Size)
 
 		// TODO: 0 bytesのファイルはアップロードがされていないため、空ファイルを作る
-		var err error
 		data := []byte{}
 		if c.Size > 0 {
-			data, err = d.Fetch(c.Hash, c.Attr)
+			data, err = d.fetch(c.Hash, c.Attr, func(n int64) { reporter.OnBytes(c.Path, n) })
 			if err != nil {
+				reporter.OnFileDone(c.Path, err)
 				return err
 			}
 		}
 
 		err = os.MkdirAll(filepath.Dir(filepath.Join(dir, filepath.FromSlash(c.Path))), 0777)
 		if err != nil {
+			reporter.OnFileDone(c.Path, err)
 			return err
 		}
 
 		err = atomic.WriteFile(filepath.Join(dir, filepath.FromSlash(c.Path)), bytes.NewBuffer(data))
+		reporter.OnFileDone(c.Path, err)
 		if err != nil {
 			return err
 		}
@@ -138,7 +238,9 @@ func (d *Downloader) Sync(b *Bucket, dir string) error {
 }
 
 func (d *Downloader) FetchAll(b *Bucket) error {
-	const RETRY_LIMIT = 3
+	reporter := d.reporter()
+	reporter.OnStart(int64(len(b.Contents)), totalSize(b))
+
 	limit := make(chan struct{}, 8)
 	eg, ctx := errgroup.WithContext(context.Background())
 	ctx, cancel := context.WithCancel(ctx)
@@ -156,36 +258,27 @@ func (d *Downloader) FetchAll(b *Bucket) error {
 				if Verbose {
 					fmt.Printf("downloading %s\n", c.Path)
 				}
+				reporter.OnFileStart(c.Path, c.Hash, c.Size)
 
 				// TODO: 0 bytesのファイルはアップロードがされていないため、空ファイルを作る
 				if c.Size == 0 {
-					os.Create(filepath.Join(GlobalDataCacheDir(), c.Hash))
-					return nil
-				}
-
-				retryCount := 0
-				for {
-					_, err := d.Fetch(c.Hash, c.Attr)
-					if err != nil {
-						if retryCount < RETRY_LIMIT {
-							retryCount++
-							fmt.Printf("retry for %v, retry count %d\n", err, retryCount)
-							continue
-						} else {
-							return err
-						}
-					} else {
-						break
-					}
+					err := d.Cache.Put(c.Hash, bytes.NewReader(nil))
+					reporter.OnFileDone(c.Path, err)
+					return err
 				}
 
+				// Per-chunk retries with backoff now live inside Fetch, so a
+				// single call here is enough.
+				_, err := d.fetch(c.Hash, c.Attr, func(n int64) { reporter.OnBytes(c.Path, n) })
+				reporter.OnFileDone(c.Path, err)
+				return err
 			}
-			return nil
 		})
 	}
 
 	err := eg.Wait()
 	cancel()
+	reporter.OnDone(err)
 	if err != nil {
 		return err
 	}
@@ -193,96 +286,429 @@ func (d *Downloader) FetchAll(b *Bucket) error {
 	return nil
 }
 
+func totalSize(b *Bucket) int64 {
+	var total int64
+	for _, c := range b.Contents {
+		total += c.Size
+	}
+	return total
+}
+
+// Fetch downloads hash and returns its decoded bytes. Files are streamed to a
+// temp file next to their cache entry and fetched with ranged GETs so an
+// interrupted download resumes from the last verified offset instead of
+// starting over; servers that don't actually honor Range requests fall back
+// to a single-shot Get. The assembled file's MD5 is verified against hash
+// before it replaces the cache entry, and again every time Fetch reads it
+// back out of the cache, so a cache entry corrupted after that write is
+// never served silently.
 func (d *Downloader) Fetch(hash string, attr ContentAttribute) ([]byte, error) {
+	return d.fetch(hash, attr, nil)
+}
+
+// fetch is Fetch plus an optional onBytes hook, called as bytes are
+// streamed in, that FetchAll/Sync use to drive their ProgressReporter. A
+// cache hit reports its whole size at once, since there's nothing to stream.
+func (d *Downloader) fetch(hash string, attr ContentAttribute, onBytes func(int64)) ([]byte, error) {
 	if !isHash(hash) {
 		return nil, fmt.Errorf("cannot fetch data, %s is not a hash", hash)
 	}
 
-	var data []byte
-
-	// データをキャッシュしているパス取得
-	cache := filepath.Join(GlobalDataCacheDir(), hash)
-	_, err := os.Stat(cache)
-	if !os.IsNotExist(err) {
-		data, err = ioutil.ReadFile(cache)
+	if r, ok := d.Cache.Get(hash); ok {
+		data, err := ioutil.ReadAll(r)
+		r.Close()
 		if err != nil {
 			return nil, err
 		}
-	} else {
-
-		// ダウンロードURL取得
-		fetchUrl, err := d.dataUrl(hash)
-		if err != nil {
+		if err := verifyMD5(hash, data); err != nil {
 			return nil, err
 		}
+		if onBytes != nil {
+			onBytes(int64(len(data)))
+		}
+		return decode(data, Option.EncryptKey, Option.EncryptIv, attr)
+	}
 
-		// ファイルダウンロード
-		data, err = fetch(fetchUrl)
-		if err != nil {
-			return nil, err
+	if d.PackIndex != nil {
+		if entry, ok := d.PackIndex.Entries[hash]; ok {
+			data, err := d.fetchPacked(hash, entry, attr)
+			if err == nil && onBytes != nil {
+				onBytes(entry.Size)
+			}
+			return data, err
+		}
+	}
+
+	err := retryWithBackoff(chunkRetryLimit, func() error {
+		return d.fetchToCache(hash, d.Cache.Path(hash), onBytes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Cache.Register(hash); err != nil {
+		return nil, err
+	}
+
+	r, ok := d.Cache.Get(hash)
+	if !ok {
+		return nil, fmt.Errorf("%s was fetched but is missing from cache", hash)
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyMD5(hash, data); err != nil {
+		return nil, err
+	}
+
+	return decode(data, Option.EncryptKey, Option.EncryptIv, attr)
+}
+
+// fetchToCache downloads hash from d.Backend into cachePath, using ranged
+// chunked requests with resume support when the backend implements
+// backend.RangeGetter, and falling back to a single-shot Get otherwise.
+// Implementing backend.RangeGetter only means the Go type knows how to issue
+// a ranged request; for backends that also implement backend.RangeProber
+// (plain HTTP, where an origin may silently ignore Range and return the
+// full body at every offset), that's checked too before the chunked path is
+// attempted. As a last-resort safety net, a checksum mismatch on the
+// chunked path is still treated as evidence of a non-range-capable server
+// and retried once as a single-shot Get before giving up. onBytes, if
+// non-nil, is called with the size of each piece of hash's content as it's
+// streamed in.
+func (d *Downloader) fetchToCache(hash, cachePath string, onBytes func(int64)) error {
+	size, exists, err := d.Backend.Head(context.Background(), hash)
+	rangeBackend, supportsRange := d.Backend.(backend.RangeGetter)
+
+	if supportsRange {
+		if prober, ok := d.Backend.(backend.RangeProber); ok {
+			probed, probeErr := prober.SupportsRange(context.Background(), hash)
+			supportsRange = probeErr == nil && probed
 		}
+	}
+
+	if err != nil || !exists || !supportsRange || size <= 0 {
+		return d.fetchWholeToCache(hash, cachePath, onBytes)
+	}
+
+	err = d.fetchRangedToCache(rangeBackend, hash, cachePath, size, onBytes)
+	if _, mismatch := err.(*checksumMismatchError); mismatch {
+		return d.fetchWholeToCache(hash, cachePath, onBytes)
+	}
+	return err
+}
 
-		// データファイルをキャッシュする
-		err = atomic.WriteFile(cache, bytes.NewBuffer(data))
+// fetchWholeToCache downloads hash in a single Get and writes it straight to
+// cachePath; it never touches a .tmp/.progress sidecar since there's nothing
+// to resume.
+func (d *Downloader) fetchWholeToCache(hash, cachePath string, onBytes func(int64)) error {
+	r, _, err := d.Backend.Get(context.Background(), hash)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	if err := verifyMD5(hash, data); err != nil {
+		return err
+	}
+	if onBytes != nil {
+		onBytes(int64(len(data)))
+	}
+	return atomic.WriteFile(cachePath, bytes.NewBuffer(data))
+}
+
+// fetchRangedToCache downloads hash in chunks via rangeBackend, resuming from
+// a .progress sidecar next to cachePath. On checksum mismatch the temp file
+// and sidecar are discarded and a *checksumMismatchError is returned so the
+// caller can decide whether to retry the ranged path or fall back to a
+// single-shot Get.
+func (d *Downloader) fetchRangedToCache(rangeBackend backend.RangeGetter, hash, cachePath string, size int64, onBytes func(int64)) error {
+	tmpPath := cachePath + ".tmp"
+	progressPath := cachePath + ".progress"
+
+	prog, err := loadDownloadProgress(progressPath, size)
+	if err != nil {
+		return err
+	}
+
+	if err := d.downloadChunks(rangeBackend, hash, tmpPath, prog, progressPath, onBytes); err != nil {
+		return err
+	}
+
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return err
+	}
+	sum := md5.New()
+	_, err = io.Copy(sum, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(sum.Sum(nil)) != hash {
+		os.Remove(tmpPath)
+		os.Remove(progressPath)
+		return &checksumMismatchError{hash: hash}
+	}
+
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		return err
+	}
+	os.Remove(progressPath)
+
+	return nil
+}
+
+// checksumMismatchError marks a checksum failure on the ranged download path
+// specifically, distinguishing "the bytes we got don't match" (which may
+// mean the server silently ignored our Range header) from other fetch
+// failures, so fetchToCache knows when a single-shot Get fallback is worth
+// trying.
+type checksumMismatchError struct {
+	hash string
+}
+
+func (e *checksumMismatchError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s, discarding and retrying", e.hash)
+}
+
+// fetchPacked serves hash by downloading (or reusing a cached copy of) its
+// enclosing pack named by entry.PackHash through d.Cache, then slicing out
+// entry's byte range instead of fetching the content on its own.
+func (d *Downloader) fetchPacked(hash string, entry pack.IndexEntry, attr ContentAttribute) ([]byte, error) {
+	packCache := d.Cache.Path(entry.PackHash)
+
+	if r, ok := d.Cache.Get(entry.PackHash); ok {
+		r.Close()
+	} else {
+		err := retryWithBackoff(chunkRetryLimit, func() error {
+			return d.fetchToCache(entry.PackHash, packCache, nil)
+		})
 		if err != nil {
 			return nil, err
 		}
+		if err := d.Cache.Register(entry.PackHash); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := os.Open(packCache)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data := make([]byte, entry.Size)
+	if _, err := f.ReadAt(data, entry.Offset); err != nil {
+		return nil, err
+	}
+	if err := verifyMD5(hash, data); err != nil {
+		return nil, err
 	}
 
 	return decode(data, Option.EncryptKey, Option.EncryptIv, attr)
 }
 
+// downloadChunks fetches every byte range of prog not yet marked complete
+// via rangeBackend, writing each directly into its offset in tmpPath and
+// persisting progress after every chunk so a crash mid-download loses only
+// the ranges still in flight (up to ChunkConcurrency of them). onBytes, if
+// non-nil, is called with the size of
+// each chunk as it's written.
+func (d *Downloader) downloadChunks(rangeBackend backend.RangeGetter, hash, tmpPath string, prog *downloadProgress, progressPath string, onBytes func(int64)) error {
+	if err := ensureFileSize(tmpPath, prog.Size); err != nil {
+		return err
+	}
+
+	ranges := prog.missingRanges(d.chunkSize())
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	var mutex sync.Mutex
+	limit := make(chan struct{}, d.chunkConcurrency())
+	eg, ctx := errgroup.WithContext(context.Background())
+
+	for _, rng := range ranges {
+		rng := rng
+		eg.Go(func() error {
+			limit <- struct{}{}
+			defer func() { <-limit }()
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			default:
+			}
+
+			return retryWithBackoff(chunkRetryLimit, func() error {
+				r, err := rangeBackend.GetRange(ctx, hash, rng.Start, rng.End)
+				if err != nil {
+					return err
+				}
+				data, err := ioutil.ReadAll(r)
+				r.Close()
+				if err != nil {
+					return err
+				}
+
+				f, err := os.OpenFile(tmpPath, os.O_WRONLY, 0666)
+				if err != nil {
+					return err
+				}
+				_, err = f.WriteAt(data, rng.Start)
+				f.Close()
+				if err != nil {
+					return err
+				}
+
+				if onBytes != nil {
+					onBytes(int64(len(data)))
+				}
+
+				mutex.Lock()
+				prog.markComplete(rng)
+				err = prog.save(progressPath)
+				mutex.Unlock()
+				return err
+			})
+		})
+	}
+
+	return eg.Wait()
+}
+
 func (d *Downloader) FetchTag(tag string) ([]byte, error) {
+	return d.Backend.GetTag(context.Background(), tag)
+}
 
-	fetchUrl, err := d.BaseUrl.Parse("tag/" + tag)
-	if err != nil {
-		return nil, err
+// resolveTagHash extracts the content hash a tag points at. A plain-text
+// tag is just the hash; a signed tag is a TagFile, whose signature (if any)
+// must verify before its Hash is trusted.
+func resolveTagHash(data []byte) (string, error) {
+	var tag TagFile
+	if err := json.Unmarshal(data, &tag); err != nil || tag.Hash == "" {
+		return strings.TrimSpace(string(data)), nil
 	}
 
-	data, err := fetch(fetchUrl)
-	if err != nil {
-		return nil, err
+	if len(tag.Signature) > 0 {
+		if err := tag.Verify(); err != nil {
+			return "", err
+		}
 	}
 
-	return data, nil
+	return tag.Hash, nil
 }
 
-func (d *Downloader) dataUrl(hash string) (*url.URL, error) {
-	return d.BaseUrl.Parse(fmt.Sprintf("data/%s/%s", hash[0:2], hash[2:]))
+func verifyMD5(hash string, data []byte) error {
+	sum := md5.Sum(data)
+	if hex.EncodeToString(sum[:]) != hash {
+		return fmt.Errorf("checksum mismatch for %s", hash)
+	}
+	return nil
 }
 
-func getRequest(_url *url.URL) (*http.Response, error) {
-	t := &http.Transport{}
-	if isWindows() {
-		t.RegisterProtocol("file", http.NewFileTransport(http.Dir("")))
-	} else {
-		t.RegisterProtocol("file", http.NewFileTransport(http.Dir("/")))
+func ensureFileSize(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return err
 	}
-	c := &http.Client{Transport: t}
-	return c.Get(_url.String())
+	defer f.Close()
+	return f.Truncate(size)
 }
 
-func headRequest(t *http.Transport, _url *url.URL) (*http.Response, error) {
-	c := &http.Client{Transport: t}
-	return c.Head(_url.String())
+func backoffDelay(attempt int) time.Duration {
+	delay := time.Duration(1<<uint(attempt)) * 200 * time.Millisecond
+	if delay > 10*time.Second {
+		return 10 * time.Second
+	}
+	return delay
+}
+
+func retryWithBackoff(limit int, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < limit; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoffDelay(attempt))
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
 }
 
-func fetch(_url *url.URL) ([]byte, error) {
-	res, err := getRequest(_url)
+// byteRange is a half-open [Start, End) span of a file being downloaded.
+type byteRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// downloadProgress is the JSON sidecar persisted next to an in-progress
+// download so a resumed Fetch can skip byte ranges already verified on disk.
+type downloadProgress struct {
+	Size      int64       `json:"size"`
+	Completed []byteRange `json:"completed"`
+}
+
+func loadDownloadProgress(path string, size int64) (*downloadProgress, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &downloadProgress{Size: size}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if res.StatusCode >= 400 {
-		return nil, fmt.Errorf("bad response status code %d from %v", res.StatusCode, _url)
+	var prog downloadProgress
+	if err := json.Unmarshal(data, &prog); err != nil || prog.Size != size {
+		// Sidecar is stale or corrupt; start the download over rather than
+		// trusting ranges that may not match the current remote file.
+		return &downloadProgress{Size: size}, nil
 	}
 
-	defer res.Body.Close()
+	return &prog, nil
+}
 
-	contents, err := ioutil.ReadAll(res.Body)
+func (p *downloadProgress) save(path string) error {
+	data, err := json.Marshal(p)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	return atomic.WriteFile(path, bytes.NewBuffer(data))
+}
+
+func (p *downloadProgress) markComplete(r byteRange) {
+	p.Completed = append(p.Completed, r)
+}
+
+// missingRanges splits the file into chunkSize-sized spans and returns the
+// ones not already covered by p.Completed.
+func (p *downloadProgress) missingRanges(chunkSize int64) []byteRange {
+	isDone := func(start, end int64) bool {
+		for _, c := range p.Completed {
+			if c.Start <= start && end <= c.End {
+				return true
+			}
+		}
+		return false
+	}
+
+	var ranges []byteRange
+	for start := int64(0); start < p.Size; start += chunkSize {
+		end := start + chunkSize
+		if end > p.Size {
+			end = p.Size
+		}
+		if !isDone(start, end) {
+			ranges = append(ranges, byteRange{Start: start, End: end})
+		}
 	}
 
-	return contents, nil
+	return ranges
 }
@@ -0,0 +1,96 @@
+package cfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDownloadProgressResumesFromSidecar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress")
+
+	prog := &downloadProgress{Size: 100}
+	prog.markComplete(byteRange{Start: 0, End: 40})
+	if err := prog.save(path); err != nil {
+		t.Error(err)
+		return
+	}
+
+	resumed, err := loadDownloadProgress(path, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	missing := resumed.missingRanges(40)
+	want := []byteRange{{Start: 40, End: 80}, {Start: 80, End: 100}}
+	if !reflect.DeepEqual(missing, want) {
+		t.Errorf("got %v, want %v", missing, want)
+	}
+}
+
+func TestLoadDownloadProgressDiscardsStaleSidecar(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress")
+
+	prog := &downloadProgress{Size: 100}
+	prog.markComplete(byteRange{Start: 0, End: 100})
+	if err := prog.save(path); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// The remote file's size has changed since the sidecar was written, so
+	// its completed ranges can no longer be trusted.
+	resumed, err := loadDownloadProgress(path, 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if resumed.Size != 200 {
+		t.Errorf("got size %d, want 200", resumed.Size)
+	}
+	if len(resumed.Completed) != 0 {
+		t.Errorf("expected stale progress to be discarded, got %v", resumed.Completed)
+	}
+}
+
+// fakeRangeGetter serves every GetRange out of a fixed byte slice, for
+// exercising downloadChunks/fetchRangedToCache without a real backend.
+type fakeRangeGetter struct {
+	data []byte
+}
+
+func (f *fakeRangeGetter) GetRange(ctx context.Context, hash string, start, end int64) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bytes.NewReader(f.data[start:end])), nil
+}
+
+func TestFetchRangedToCacheChecksumMismatchCleansUp(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, "cached")
+
+	d := &Downloader{}
+	rb := &fakeRangeGetter{data: []byte("hello world")}
+
+	// "deadbeef" never matches the MD5 of "hello world", so this should
+	// fail the post-download checksum check.
+	err := d.fetchRangedToCache(rb, "deadbeef", cachePath, int64(len(rb.data)), nil)
+	if _, ok := err.(*checksumMismatchError); !ok {
+		t.Fatalf("got error %v, want *checksumMismatchError", err)
+	}
+
+	if _, err := os.Stat(cachePath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected .tmp to be removed after checksum mismatch, stat err: %v", err)
+	}
+	if _, err := os.Stat(cachePath + ".progress"); !os.IsNotExist(err) {
+		t.Errorf("expected .progress to be removed after checksum mismatch, stat err: %v", err)
+	}
+	if _, err := os.Stat(cachePath); !os.IsNotExist(err) {
+		t.Errorf("expected cache entry to not exist after checksum mismatch, stat err: %v", err)
+	}
+}
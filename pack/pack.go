@@ -0,0 +1,184 @@
+package pack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// PackFileVersion is the current on-disk format version for PackFile.
+const PackFileVersion = 1
+
+// packMagic identifies a serialized PackFile header.
+const packMagic = "CFSPACK1"
+
+// Entry describes one blob bundled into a pack: the path it was built from,
+// its content hash, and the byte range it occupies in the pack's data
+// section (the bytes immediately following the serialized header).
+type Entry struct {
+	Path string
+	Hash string
+	Pos  int64
+	Size int64
+}
+
+// PackFile is a pack's header: the ordered list of entries whose blob bytes
+// follow it on disk. Entry.Pos is relative to the start of that data
+// section, not the start of the file.
+type PackFile struct {
+	Version int
+	Entries []Entry
+}
+
+func NewPackFile(entries []Entry) *PackFile {
+	return &PackFile{
+		Version: PackFileVersion,
+		Entries: entries,
+	}
+}
+
+// Write serializes pack's header and entries to w. It does not write blob
+// data; Build uses it to lay out the header before streaming each entry's
+// bytes.
+func Write(w io.Writer, pack *PackFile) error {
+	bw := bufio.NewWriter(w)
+
+	if _, err := bw.WriteString(packMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, uint32(pack.Version)); err != nil {
+		return err
+	}
+	if err := binary.Write(bw, binary.BigEndian, uint32(len(pack.Entries))); err != nil {
+		return err
+	}
+
+	for _, e := range pack.Entries {
+		if err := writeString(bw, e.Path); err != nil {
+			return err
+		}
+		if err := writeString(bw, e.Hash); err != nil {
+			return err
+		}
+		if err := binary.Write(bw, binary.BigEndian, e.Pos); err != nil {
+			return err
+		}
+		if err := binary.Write(bw, binary.BigEndian, e.Size); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Parse reads a PackFile header previously written by Write.
+func Parse(r io.Reader) (*PackFile, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(packMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != packMagic {
+		return nil, fmt.Errorf("not a pack file (bad magic %q)", magic)
+	}
+
+	var version, count uint32
+	if err := binary.Read(br, binary.BigEndian, &version); err != nil {
+		return nil, err
+	}
+	if err := binary.Read(br, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, count)
+	for i := range entries {
+		path, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := readString(br)
+		if err != nil {
+			return nil, err
+		}
+		var pos, size int64
+		if err := binary.Read(br, binary.BigEndian, &pos); err != nil {
+			return nil, err
+		}
+		if err := binary.Read(br, binary.BigEndian, &size); err != nil {
+			return nil, err
+		}
+		entries[i] = Entry{Path: path, Hash: hash, Pos: pos, Size: size}
+	}
+
+	return &PackFile{Version: int(version), Entries: entries}, nil
+}
+
+// HeaderSize returns the number of bytes Write would emit for pack, i.e.
+// where pack's data section begins once it's written to disk.
+func HeaderSize(pack *PackFile) (int64, error) {
+	var buf bytes.Buffer
+	if err := Write(&buf, pack); err != nil {
+		return 0, err
+	}
+	return int64(buf.Len()), nil
+}
+
+// Build writes a complete pack to w: a header followed immediately by every
+// entry's blob bytes, in order. open is called once per entry to obtain its
+// data; the returned PackFile has Entry.Pos set to each blob's offset within
+// the data section so a reader can later seek to HeaderSize()+Pos to find it.
+func Build(w io.Writer, rawEntries []Entry, open func(hash string) (io.Reader, error)) (*PackFile, error) {
+	entries := make([]Entry, len(rawEntries))
+	copy(entries, rawEntries)
+
+	var offset int64
+	for i, e := range entries {
+		entries[i].Pos = offset
+		offset += e.Size
+	}
+
+	packFile := NewPackFile(entries)
+
+	if err := Write(w, packFile); err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		r, err := open(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(w, r)
+		if c, ok := r.(io.Closer); ok {
+			c.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return packFile, nil
+}
+
+func writeString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.BigEndian, uint32(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+func readString(r io.Reader) (string, error) {
+	var n uint32
+	if err := binary.Read(r, binary.BigEndian, &n); err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,45 @@
+package pack
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// IndexEntry locates one content hash inside a pack: which pack file it
+// lives in and the absolute byte range to slice out of it once the pack is
+// cached locally (Offset already accounts for the pack's own header, i.e.
+// it equals HeaderSize()+Entry.Pos at the time the pack was built).
+type IndexEntry struct {
+	PackHash string `json:"packHash"`
+	Offset   int64  `json:"offset"`
+	Size     int64  `json:"size"`
+}
+
+// PackIndex maps every packed content hash in a Bucket to its location. A
+// Downloader fetches it once per Bucket so Fetch can tell, without any
+// further network round-trip, whether a hash should be served by slicing a
+// cached pack instead of downloading it directly.
+type PackIndex struct {
+	Entries map[string]IndexEntry `json:"entries"`
+}
+
+func NewPackIndex() *PackIndex {
+	return &PackIndex{Entries: make(map[string]IndexEntry)}
+}
+
+// WriteIndex serializes idx as JSON to w.
+func WriteIndex(w io.Writer, idx *PackIndex) error {
+	return json.NewEncoder(w).Encode(idx)
+}
+
+// ParseIndex reads a PackIndex previously written by WriteIndex.
+func ParseIndex(r io.Reader) (*PackIndex, error) {
+	var idx PackIndex
+	if err := json.NewDecoder(r).Decode(&idx); err != nil {
+		return nil, err
+	}
+	if idx.Entries == nil {
+		idx.Entries = make(map[string]IndexEntry)
+	}
+	return &idx, nil
+}
@@ -2,15 +2,17 @@ package pack
 
 import (
 	"bytes"
+	"io"
+	"strings"
 	"testing"
 )
 
 func TestPack(t *testing.T) {
 	hash := "0123456789abcdef0123456789abcdef"
 	entries := []Entry{
-		{path: "hoge", hash: hash, pos: 0, size: 1},
-		{path: "fugafuga", hash: hash, pos: 0, size: 100},
-		{path: "piyo", hash: hash, pos: 0, size: 0},
+		{Path: "hoge", Hash: hash, Pos: 0, Size: 1},
+		{Path: "fugafuga", Hash: hash, Pos: 0, Size: 100},
+		{Path: "piyo", Hash: hash, Pos: 0, Size: 0},
 	}
 	w := bytes.NewBuffer(nil)
 	origPack := NewPackFile(entries)
@@ -36,3 +38,97 @@ func TestPack(t *testing.T) {
 		}
 	}
 }
+
+func TestBuild(t *testing.T) {
+	blobs := map[string]string{
+		"hoge":     "hello",
+		"fugafuga": "world!",
+	}
+	entries := []Entry{
+		{Path: "hoge", Hash: "hoge", Size: int64(len(blobs["hoge"]))},
+		{Path: "fugafuga", Hash: "fugafuga", Size: int64(len(blobs["fugafuga"]))},
+	}
+
+	w := bytes.NewBuffer(nil)
+	built, err := Build(w, entries, func(hash string) (io.Reader, error) {
+		return strings.NewReader(blobs[hash]), nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	headerSize, err := HeaderSize(built)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	bin := w.Bytes()
+	for _, e := range built.Entries {
+		got := string(bin[headerSize+e.Pos : headerSize+e.Pos+e.Size])
+		if got != blobs[e.Hash] {
+			t.Errorf("entry %s: got %q, want %q", e.Path, got, blobs[e.Hash])
+		}
+	}
+}
+
+type closeCountingReader struct {
+	io.Reader
+	closed *int
+}
+
+func (r closeCountingReader) Close() error {
+	*r.closed = *r.closed + 1
+	return nil
+}
+
+func TestBuildClosesReaders(t *testing.T) {
+	blobs := map[string]string{
+		"hoge":     "hello",
+		"fugafuga": "world!",
+	}
+	entries := []Entry{
+		{Path: "hoge", Hash: "hoge", Size: int64(len(blobs["hoge"]))},
+		{Path: "fugafuga", Hash: "fugafuga", Size: int64(len(blobs["fugafuga"]))},
+	}
+
+	closed := 0
+	w := bytes.NewBuffer(nil)
+	_, err := Build(w, entries, func(hash string) (io.Reader, error) {
+		return closeCountingReader{Reader: strings.NewReader(blobs[hash]), closed: &closed}, nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if closed != len(entries) {
+		t.Errorf("got %d readers closed, want %d", closed, len(entries))
+	}
+}
+
+func TestPackIndex(t *testing.T) {
+	idx := NewPackIndex()
+	idx.Entries["0123456789abcdef0123456789abcdef"] = IndexEntry{
+		PackHash: "fedcba9876543210fedcba9876543210",
+		Offset:   128,
+		Size:     64,
+	}
+
+	w := bytes.NewBuffer(nil)
+	if err := WriteIndex(w, idx); err != nil {
+		t.Error(err)
+		return
+	}
+
+	parsed, err := ParseIndex(bytes.NewReader(w.Bytes()))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if parsed.Entries["0123456789abcdef0123456789abcdef"] != idx.Entries["0123456789abcdef0123456789abcdef"] {
+		t.Errorf("not same index entry %v", parsed.Entries)
+	}
+}
@@ -0,0 +1,124 @@
+package cfs
+
+import (
+	"io"
+	"sync"
+
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+// ProgressReporter receives structured events as Downloader.FetchAll and
+// Sync move content, so callers can drive a progress bar, a structured log,
+// or a GUI instead of parsing the Verbose fmt.Printf output. Implementations
+// must be safe for concurrent use: FetchAll calls them from multiple
+// goroutines, one per file in flight.
+type ProgressReporter interface {
+	// OnStart is called once, before any file is fetched, with the total
+	// number of files and bytes the operation expects to move.
+	OnStart(totalFiles, totalBytes int64)
+
+	// OnFileStart is called when path begins fetching.
+	OnFileStart(path, hash string, size int64)
+
+	// OnBytes is called as path's content streams in; delta is the number
+	// of additional bytes since the last call for path, not a running
+	// total. A cache hit reports its whole size in a single call, since
+	// there's nothing to stream.
+	OnBytes(path string, delta int64)
+
+	// OnFileDone is called when path finishes; err is non-nil on failure.
+	OnFileDone(path string, err error)
+
+	// OnDone is called once the whole operation finishes; err is non-nil
+	// if any file failed.
+	OnDone(err error)
+}
+
+// WithReporter sets r as d's Reporter and returns d, so it can be chained
+// onto NewDownloader.
+func WithReporter(d *Downloader, r ProgressReporter) *Downloader {
+	d.Reporter = r
+	return d
+}
+
+// silentReporter is Downloader's default Reporter: it does nothing.
+type silentReporter struct{}
+
+func (silentReporter) OnStart(totalFiles, totalBytes int64)      {}
+func (silentReporter) OnFileStart(path, hash string, size int64) {}
+func (silentReporter) OnBytes(path string, delta int64)          {}
+func (silentReporter) OnFileDone(path string, err error)         {}
+func (silentReporter) OnDone(err error)                          {}
+
+// TerminalReporter renders per-file and aggregate progress bars to an
+// io.Writer (typically os.Stderr) using mpb's multi-bar renderer, with a
+// bytes/sec rate on the aggregate bar.
+type TerminalReporter struct {
+	mutex sync.Mutex
+	p     *mpb.Progress
+	total *mpb.Bar
+	bars  map[string]*mpb.Bar
+	w     io.Writer
+}
+
+// NewTerminalReporter builds a TerminalReporter that renders to w.
+func NewTerminalReporter(w io.Writer) *TerminalReporter {
+	return &TerminalReporter{w: w, bars: make(map[string]*mpb.Bar)}
+}
+
+func (t *TerminalReporter) OnStart(totalFiles, totalBytes int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.p = mpb.New(mpb.WithOutput(t.w))
+	t.total = t.p.AddBar(totalBytes,
+		mpb.PrependDecorators(decor.Name("total")),
+		mpb.AppendDecorators(decor.AverageSpeed(decor.SizeB1024(0), "% .1f/s"), decor.Percentage()),
+	)
+}
+
+func (t *TerminalReporter) OnFileStart(path, hash string, size int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.p == nil {
+		return
+	}
+	t.bars[path] = t.p.AddBar(size,
+		mpb.PrependDecorators(decor.Name(path, decor.WCSyncWidthR)),
+		mpb.AppendDecorators(decor.Percentage()),
+	)
+}
+
+func (t *TerminalReporter) OnBytes(path string, delta int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if bar, ok := t.bars[path]; ok {
+		bar.IncrInt64(delta)
+	}
+	if t.total != nil {
+		t.total.IncrInt64(delta)
+	}
+}
+
+func (t *TerminalReporter) OnFileDone(path string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if bar, ok := t.bars[path]; ok {
+		bar.Abort(err != nil)
+		delete(t.bars, path)
+	}
+}
+
+func (t *TerminalReporter) OnDone(err error) {
+	t.mutex.Lock()
+	p := t.p
+	t.mutex.Unlock()
+
+	if p != nil {
+		p.Wait()
+	}
+}
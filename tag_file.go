@@ -14,6 +14,14 @@ type TagFile struct {
 	EncryptIv  string           `json:"encryptIv"`
 	Attr       ContentAttribute `json:"attr"`
 	Hash       string           `json:"hash"`
+
+	// Signature and PublicKeyID make this tag a signed manifest: Signature
+	// is an Ed25519 signature (see SignTagFile) over the canonical
+	// serialization of Name, Hash, CreatedAt, and Attr, and PublicKeyID
+	// names the key registered via RegisterVerifier to check it with.
+	// Both are empty for an unsigned tag.
+	Signature   []byte `json:"signature,omitempty"`
+	PublicKeyID string `json:"publicKeyId,omitempty"`
 }
 
 func TagFileFromReader(r io.Reader) (*TagFile, error) {